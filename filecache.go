@@ -4,60 +4,272 @@ The filecache package provides a simple file based cache with TTL (time-to-live)
 package filecache
 
 import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
 	"os"
+	"path/filepath"
 	"sync"
 	"time"
 )
 
 type FileCache struct {
-	opts        options
-	workdir     string
-	stopCleaner chan struct{}
+	opts    options
+	workdir string
 
-	cache map[string]item
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+
+	cache map[string]*item
 	lock  sync.RWMutex
+
+	// lru and lruElems implement least-recently-used eviction for WithMaxBytes/WithMaxEntries. Both are guarded
+	// by lock, the same lock that guards cache, since eviction always needs to remove entries from cache too.
+	lru        *list.List
+	lruElems   map[string]*list.Element
+	totalBytes int64
+
+	statsMu   sync.Mutex
+	hits      int64
+	misses    int64
+	evictions int64
 }
 
 // Cannot find cache for the key.
 var ErrNotFound = fmt.Errorf("not found")
 
+// The key was populated through PutRange and may be only partially present, so it cannot be served through the
+// whole-value Get/Put API. Use GetRange/Has instead.
+var ErrRangePopulated = fmt.Errorf("key was populated via PutRange; use GetRange/Has instead")
+
+// The key was populated through the whole-value Put API, so it has no chunk bitmap to append to. Use Get/Put
+// instead.
+var ErrWholeValuePopulated = fmt.Errorf("key was populated via Put; use Get/Put instead")
+
+// item tracks a single cached entry backed by a file on disk. While the value is being written, concurrent
+// readers are allowed to read it as it grows: cond is used to wake up readers blocked waiting for more bytes to
+// be flushed, or for the writer to finish (successfully or not).
 type item struct {
 	expiredAt time.Time
 	file      *os.File
+
+	// path is the entry's canonical data file path on disk. It is used to locate the metadata/lock sidecars of a
+	// durable-mode entry, since *os.File.Name() keeps returning the name the file was opened under even after it
+	// has been renamed.
+	path string
+
+	// size is the entry's accounted size in bytes, used for WithMaxBytes bookkeeping. It is maintained by
+	// whichever of Put/PutRange populated the item, guarded by FileCache.lock rather than mu.
+	size int64
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	written int64
+	closed  bool
+	err     error
+
+	// readers counts in-flight Get/GetRange calls reading from file. closeFile defers actually closing the fd
+	// until this drops back to 0, so an eviction or expiry racing with a long streamed read doesn't sever it.
+	readers      int
+	closePending bool
+
+	// chunks tracks which byte ranges have been populated when the item is used through PutRange/GetRange. It is
+	// nil for items only ever populated through Put.
+	chunks *chunkMap
+
+	// checksum accumulates a running hash of the bytes written so far. It is only set in durable mode, where the
+	// digest is recorded in the entry's metadata sidecar once the item is closed.
+	checksum hash.Hash
 }
 
-// Create a new FileCache.
-func New(opts ...Option) (*FileCache, error) {
-	workdir, err := os.MkdirTemp("", "filecache-*")
+func newItem(expiredAt time.Time, file *os.File) *item {
+	i := &item{
+		expiredAt: expiredAt,
+		file:      file,
+	}
+	i.cond = sync.NewCond(&i.mu)
+
+	return i
+}
+
+// write appends p to the underlying file and wakes up any reader waiting for more data.
+func (i *item) write(p []byte) error {
+	_, err := i.file.Write(p)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	fc := &FileCache{
-		workdir:     workdir,
-		stopCleaner: make(chan struct{}),
-		cache:       make(map[string]item),
+	i.mu.Lock()
+	i.written += int64(len(p))
+	if i.checksum != nil {
+		i.checksum.Write(p)
 	}
+	i.cond.Broadcast()
+	i.mu.Unlock()
+
+	return nil
+}
+
+// close marks the item as fully written. err, if non-nil, is surfaced to every reader once it reaches the end of
+// what has been flushed so far.
+func (i *item) close(err error) {
+	i.mu.Lock()
+	i.closed = true
+	i.err = err
+	i.cond.Broadcast()
+	i.mu.Unlock()
+}
+
+// closeFile closes the underlying OS file descriptor, reclaiming it once an item has been evicted or expired and
+// can no longer be looked up. It is distinct from close, which only marks the writer side finished. If a Get or
+// GetRange is still reading from file (see acquireReader/releaseReader), the close is deferred until that reader
+// finishes instead of cutting it off mid-read; releaseReader performs the close at that point.
+func (i *item) closeFile() error {
+	i.mu.Lock()
+	if i.readers > 0 {
+		i.closePending = true
+		i.mu.Unlock()
+		return nil
+	}
+	i.mu.Unlock()
+
+	return i.file.Close()
+}
+
+// acquireReader registers an in-flight reader of file, so a concurrent closeFile defers closing it until
+// releaseReader reports the last reader is done.
+func (i *item) acquireReader() {
+	i.mu.Lock()
+	i.readers++
+	i.mu.Unlock()
+}
+
+// releaseReader unregisters a reader previously registered with acquireReader, closing file if closeFile is
+// waiting on it being the last one.
+func (i *item) releaseReader() {
+	i.mu.Lock()
+	i.readers--
+	pending := i.readers == 0 && i.closePending
+	i.mu.Unlock()
+
+	if pending {
+		i.file.Close()
+	}
+}
+
+// newReader returns an io.Reader that reads the item starting at offset 0, blocking while it catches up with an
+// in-flight writer.
+func (i *item) newReader() io.Reader {
+	return &itemReader{item: i}
+}
+
+type itemReader struct {
+	item   *item
+	offset int64
+}
+
+func (r *itemReader) Read(p []byte) (int, error) {
+	i := r.item
+
+	i.mu.Lock()
+	for i.written <= r.offset && !i.closed {
+		i.cond.Wait()
+	}
+
+	if i.written <= r.offset {
+		err := i.err
+		i.mu.Unlock()
+		if err != nil {
+			return 0, err
+		}
+		return 0, io.EOF
+	}
+
+	available := i.written - r.offset
+	i.mu.Unlock()
+
+	if int64(len(p)) > available {
+		p = p[:available]
+	}
+
+	n, err := i.file.ReadAt(p, r.offset)
+	r.offset += int64(n)
+
+	return n, err
+}
+
+// Create a new FileCache. By default, the cache lives in a temporary directory that is wiped on Stop. Passing
+// WithPersistence(true) and WithPersistentDir switches to a durable mode that rebuilds its index from that
+// directory instead, so entries survive across process restarts.
+func New(opts ...Option) (*FileCache, error) {
+	fc := &FileCache{}
+	fc.ctx, fc.cancel = context.WithCancel(context.Background())
 
 	fc.opts = getDefaultOptions()
 	for _, opt := range opts {
 		opt(&fc.opts)
 	}
 
+	fc.lru = list.New()
+	fc.lruElems = make(map[string]*list.Element)
+
+	if fc.opts.persistent {
+		if err := os.MkdirAll(fc.opts.persistentDir, 0o755); err != nil {
+			return nil, err
+		}
+
+		cache, err := rebuildIndex(fc.opts.persistentDir)
+		if err != nil {
+			return nil, err
+		}
+
+		fc.workdir = fc.opts.persistentDir
+		fc.cache = cache
+		fc.seedLRU()
+
+		return fc, nil
+	}
+
+	workdir, err := os.MkdirTemp("", "filecache-*")
+	if err != nil {
+		return nil, err
+	}
+
+	fc.workdir = workdir
+	fc.cache = make(map[string]*item)
+
 	return fc, nil
 }
 
 // Start this FileCache. This will start a cleaner goroutine to clean expired key periodically. User needs to call
 // Stop afterward.
 func (fc *FileCache) Start() {
-	go fc.runCleaner()
+	fc.wg.Add(1)
+	go func() {
+		defer fc.wg.Done()
+		fc.runCleaner()
+	}()
 }
 
-// Stop this FileCache. Once this function is called, no further functions shall be called.
+// Wait blocks until the cleaner goroutine started by Start has exited. It does not wait for Stop's file removal:
+// Stop calls Wait first and removes workdir afterwards, so Stop's own return is what marks its cleanup as done.
+func (fc *FileCache) Wait() {
+	fc.wg.Wait()
+}
+
+// Stop this FileCache. Once this function is called, no further functions shall be called. Stop is idempotent and
+// safe to call more than once. It blocks until the cleaner goroutine has exited before removing any files, so
+// workdir is guaranteed gone (in non-durable mode) by the time Stop returns. In durable mode, workdir is left in
+// place so a later New can rebuild the index from it.
 func (fc *FileCache) Stop() {
-	fc.stopCleaner <- struct{}{}
+	fc.stopOnce.Do(fc.cancel)
+	fc.Wait()
 
 	fc.lock.Lock()
 	for key := range fc.cache {
@@ -65,77 +277,183 @@ func (fc *FileCache) Stop() {
 	}
 	fc.lock.Unlock()
 
+	if fc.opts.persistent {
+		return
+	}
+
 	os.RemoveAll(fc.workdir)
 }
 
-// Get the value for the given key. ErrNotFound will be returned if the key is not found.
-func (fc *FileCache) Get(key string) ([]byte, error) {
+// Get streams the value for the given key into w. ErrNotFound will be returned if the key is not found. If the
+// value is still being populated by a concurrent Put, Get blocks and streams bytes as they are written, similar to
+// a tee-pipe, until the writer closes or fails.
+func (fc *FileCache) Get(key string, w io.Writer) error {
 	fc.lock.RLock()
-	item, ok := fc.cache[key]
+	i, ok := fc.cache[key]
 	fc.lock.RUnlock()
 
 	if !ok {
-		return []byte{}, ErrNotFound
+		fc.recordMiss()
+		return ErrNotFound
 	}
 
-	fc.lock.Lock()
-	defer fc.lock.Unlock()
+	i.mu.Lock()
+	expired := time.Now().After(i.expiredAt)
+	if !expired {
+		i.expiredAt = time.Now().Add(fc.opts.timeToLive)
+	}
+	i.mu.Unlock()
+
+	if expired {
+		fc.lock.Lock()
+		fc.expireLocked(key)
+		fc.lock.Unlock()
+		fc.recordMiss()
+		return ErrNotFound
+	}
 
-	if time.Now().After(item.expiredAt) {
-		delete(fc.cache, key)
-		return []byte{}, ErrNotFound
+	if i.chunks != nil {
+		return ErrRangePopulated
 	}
 
-	item.expiredAt = time.Now().Add(fc.opts.timeToLive)
+	fc.lock.Lock()
+	fc.touchLRU(key)
+	fc.lock.Unlock()
+	fc.recordHit()
+
+	i.acquireReader()
+	defer i.releaseReader()
 
-	size, err := item.file.Seek(0, io.SeekEnd)
-	if err != nil {
-		return []byte{}, err
+	_, err := io.Copy(w, i.newReader())
+	return err
+}
+
+// Put streams value for the given key, reading from r. The value becomes visible to concurrent Get calls as soon
+// as it is created; readers that catch up to the writer block until more bytes are flushed, and any error
+// encountered while reading from r is surfaced to every attached reader. In durable mode (WithPersistence) the
+// value is written to a temporary file first and becomes visible to Get only once Put has finished writing it.
+func (fc *FileCache) Put(key string, r io.Reader) error {
+	if fc.opts.persistent {
+		return fc.putPersistent(key, r)
 	}
 
-	buf := make([]byte, size)
-	_, err = item.file.ReadAt(buf, 0)
+	f, err := os.CreateTemp(fc.workdir, "cache-*")
 	if err != nil {
-		return []byte{}, err
+		return err
+	}
+
+	i := newItem(time.Now().Add(fc.opts.timeToLive), f)
+	i.path = f.Name()
+
+	fc.lock.Lock()
+	fc.replaceLocked(key, i)
+	fc.lock.Unlock()
+
+	if err := streamInto(i, r); err != nil {
+		return err
 	}
 
-	return buf, nil
+	fc.lock.Lock()
+	fc.accountSize(key, i, i.written)
+	fc.lock.Unlock()
+
+	return nil
 }
 
-// Put the value for the given key.
-func (fc *FileCache) Put(key string, value []byte) error {
-	f, err := os.CreateTemp(fc.workdir, "cache-*")
+// putPersistent is the durable-mode counterpart of Put. It writes to a temporary file guarded by a per-key flock,
+// then atomically renames both the data file and its metadata sidecar into place so a crash never leaves a
+// half-written entry visible to a rebuildIndex on the next New.
+func (fc *FileCache) putPersistent(key string, r io.Reader) error {
+	finalPath := entryPath(fc.workdir, key)
+	dir := filepath.Dir(finalPath)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	lock, err := os.OpenFile(lockPath(finalPath), os.O_CREATE|os.O_RDWR, 0o600)
 	if err != nil {
 		return err
 	}
+	defer lock.Close()
 
-	i := item{
-		expiredAt: time.Now().Add(fc.opts.timeToLive),
-		file:      f,
+	if err := flockFile(lock); err != nil {
+		return err
 	}
+	defer funlockFile(lock)
 
-	_, err = i.file.Write(value)
+	f, err := os.CreateTemp(dir, ".cache-*")
 	if err != nil {
 		return err
 	}
+	tmpName := f.Name()
+
+	i := newItem(time.Now().Add(fc.opts.timeToLive), f)
+	i.checksum = sha256.New()
+
+	if err := streamInto(i, r); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+
+	if err := os.Rename(tmpName, finalPath); err != nil {
+		return err
+	}
+
+	meta := entryMeta{
+		Key:       key,
+		ExpiredAt: i.expiredAt,
+		Size:      i.written,
+		Checksum:  hex.EncodeToString(i.checksum.Sum(nil)),
+	}
+	if err := writeMetaAtomic(finalPath, meta); err != nil {
+		return err
+	}
+
+	i.path = finalPath
 
 	fc.lock.Lock()
-	fc.cache[key] = i
+	fc.replaceLocked(key, i)
+	fc.accountSize(key, i, i.written)
 	fc.lock.Unlock()
 
 	return nil
 }
 
+// streamInto copies r into i, chunk by chunk, surfacing any read error to i's attached readers.
+func streamInto(i *item, r io.Reader) error {
+	buf := make([]byte, 32*1024)
+	for {
+		n, rerr := r.Read(buf)
+		if n > 0 {
+			if werr := i.write(buf[:n]); werr != nil {
+				i.close(werr)
+				return werr
+			}
+		}
+
+		if rerr == io.EOF {
+			i.close(nil)
+			return nil
+		}
+		if rerr != nil {
+			i.close(rerr)
+			return rerr
+		}
+	}
+}
+
 func (fc *FileCache) runCleaner() {
 	ticker := time.NewTicker(fc.opts.cleanerInterval)
 	defer ticker.Stop()
 
-	select {
-	case <-ticker.C:
-		fc.cleanExpiredKey()
+	for {
+		select {
+		case <-ticker.C:
+			fc.cleanExpiredKey()
 
-	case <-fc.stopCleaner:
-		break
+		case <-fc.ctx.Done():
+			return
+		}
 	}
 }
 
@@ -144,8 +462,8 @@ func (fc *FileCache) cleanExpiredKey() {
 	expiredKeys := make([]string, 0)
 
 	fc.lock.RLock()
-	for key, item := range fc.cache {
-		if item.expiredAt.Before(now) {
+	for key, i := range fc.cache {
+		if i.expiredAt.Before(now) {
 			expiredKeys = append(expiredKeys, key)
 		}
 	}
@@ -154,7 +472,7 @@ func (fc *FileCache) cleanExpiredKey() {
 	fc.lock.Lock()
 	for _, key := range expiredKeys {
 		if fc.cache[key].expiredAt.Before(now) {
-			delete(fc.cache, key)
+			fc.expireLocked(key)
 		}
 	}
 	fc.lock.Unlock()
@@ -2,8 +2,12 @@ package filecache
 
 import (
 	"bytes"
+	"errors"
+	"io"
 	"os"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestGetFound(t *testing.T) {
@@ -103,6 +107,158 @@ func TestCleanExpiredKey(t *testing.T) {
 	}
 }
 
+type blockingReader struct {
+	chunks  [][]byte
+	unblock chan struct{}
+	err     error
+}
+
+func (r *blockingReader) Read(p []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		return 0, io.EOF
+	}
+
+	<-r.unblock
+
+	n := copy(p, r.chunks[0])
+	r.chunks = r.chunks[1:]
+
+	return n, nil
+}
+
+func TestGetBlocksUntilWriterFlushes(t *testing.T) {
+	fc, err := New()
+	if err != nil {
+		t.Error("cannot create filecache")
+	}
+
+	fc.Start()
+	defer fc.Stop()
+
+	key := "key"
+	r := &blockingReader{
+		chunks:  [][]byte{[]byte("foo"), []byte("bar")},
+		unblock: make(chan struct{}, 2),
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := fc.Put(key, r); err != nil {
+			t.Errorf("cannot put key: %v", err)
+		}
+	}()
+
+	// Wait for Put to register the item in the cache before attaching a reader.
+	for {
+		fc.lock.RLock()
+		_, ok := fc.cache[key]
+		fc.lock.RUnlock()
+		if ok {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	done := make(chan struct{})
+	buffer := bytes.Buffer{}
+	go func() {
+		defer close(done)
+		if err := fc.Get(key, &buffer); err != nil {
+			t.Errorf("cannot get key: %v", err)
+		}
+	}()
+
+	r.unblock <- struct{}{}
+	r.unblock <- struct{}{}
+
+	wg.Wait()
+	<-done
+
+	if !bytes.Equal(buffer.Bytes(), []byte("foobar")) {
+		t.Errorf("result is wrong, %+v != %+v", buffer.Bytes(), []byte("foobar"))
+	}
+}
+
+func TestGetSurfacesWriterError(t *testing.T) {
+	fc, err := New()
+	if err != nil {
+		t.Error("cannot create filecache")
+	}
+
+	fc.Start()
+	defer fc.Stop()
+
+	key := "key"
+	wantErr := errors.New("boom")
+	r := &blockingReader{
+		chunks:  [][]byte{[]byte("foo")},
+		unblock: make(chan struct{}, 1),
+		err:     wantErr,
+	}
+	r.unblock <- struct{}{}
+
+	if err := fc.Put(key, r); err != wantErr {
+		t.Errorf("expected writer error, got %v", err)
+	}
+
+	buffer := bytes.Buffer{}
+	if err := fc.Get(key, &buffer); err != wantErr {
+		t.Errorf("expected writer error to be surfaced to reader, got %v", err)
+	}
+}
+
+func TestCleanerRunsRepeatedly(t *testing.T) {
+	fc, err := New(WithTTL(0), WithCleanerInterval(5*time.Millisecond))
+	if err != nil {
+		t.Error("cannot create filecache")
+	}
+
+	fc.Start()
+	defer fc.Stop()
+
+	for i := 0; i < 3; i++ {
+		key := "key"
+		if err := fc.Put(key, bytes.NewReader([]byte("value"))); err != nil {
+			t.Fatalf("cannot put key: %v", err)
+		}
+
+		deadline := time.Now().Add(time.Second)
+		for {
+			fc.lock.RLock()
+			_, ok := fc.cache[key]
+			fc.lock.RUnlock()
+			if !ok {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("cleaner did not clean key on iteration %d", i)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+func TestStopIsIdempotentAndWaitsForCleaner(t *testing.T) {
+	fc, err := New()
+	if err != nil {
+		t.Error("cannot create filecache")
+	}
+
+	fc.Start()
+	fc.Stop()
+	fc.Wait()
+	fc.Stop()
+
+	if _, err := os.Stat(fc.workdir); !os.IsNotExist(err) {
+		t.Error("workdir shall be removed after Stop")
+	}
+}
+
 func TestCleanFileCache(t *testing.T) {
 	fc, err := New()
 	if err != nil {
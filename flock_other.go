@@ -0,0 +1,16 @@
+//go:build !unix
+
+package filecache
+
+import "os"
+
+// flockFile is a no-op on platforms without an advisory file-locking syscall. Durable mode still works within a
+// single process; sharing a persistent directory across processes is not guarded on these platforms.
+func flockFile(f *os.File) error {
+	return nil
+}
+
+// funlockFile is the no-op counterpart of flockFile.
+func funlockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,19 @@
+//go:build unix
+
+package filecache
+
+import (
+	"os"
+	"syscall"
+)
+
+// flockFile takes an exclusive, advisory lock on f, blocking until it is acquired. It lets multiple processes
+// share the same persistent cache directory without clobbering each other's entries.
+func flockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+}
+
+// funlockFile releases a lock taken by flockFile.
+func funlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
@@ -9,6 +9,12 @@ type Option func(*options)
 type options struct {
 	timeToLive      time.Duration
 	cleanerInterval time.Duration
+
+	persistent    bool
+	persistentDir string
+
+	maxBytes   int64
+	maxEntries int
 }
 
 func getDefaultOptions() options {
@@ -29,3 +35,36 @@ func WithCleanerInterval(interval time.Duration) func(*options) {
 		opts.cleanerInterval = interval
 	}
 }
+
+// WithPersistence enables or disables durable mode. When enabled, New rebuilds its index from WithPersistentDir
+// instead of starting from an empty, temporary workdir, and Stop leaves that directory in place.
+func WithPersistence(enabled bool) func(*options) {
+	return func(opts *options) {
+		opts.persistent = enabled
+	}
+}
+
+// WithPersistentDir sets the directory durable mode stores its data and metadata files in. It is only used when
+// WithPersistence(true) is also set.
+func WithPersistentDir(dir string) func(*options) {
+	return func(opts *options) {
+		opts.persistentDir = dir
+	}
+}
+
+// WithMaxBytes caps the total on-disk size of the cache. Once a Put/PutRange has finished writing and the total
+// exceeds it, the least recently used entries are evicted until the total is back within it. The entry just written
+// is never evicted, so a single entry larger than n stays. n <= 0 means unbounded (the default).
+func WithMaxBytes(n int64) func(*options) {
+	return func(opts *options) {
+		opts.maxBytes = n
+	}
+}
+
+// WithMaxEntries caps the number of entries in the cache, evicting least recently used entries the same way as
+// WithMaxBytes. n <= 0 means unbounded (the default).
+func WithMaxEntries(n int) func(*options) {
+	return func(opts *options) {
+		opts.maxEntries = n
+	}
+}
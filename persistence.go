@@ -0,0 +1,163 @@
+package filecache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// entryMeta is the sidecar persisted next to each entry's data file in durable mode, so New can rebuild its index
+// without re-fetching anything.
+type entryMeta struct {
+	Key       string    `json:"key"`
+	ExpiredAt time.Time `json:"expired_at"`
+	Size      int64     `json:"size"`
+	Checksum  string    `json:"checksum"` // hex sha256 of the data file; unused when Range is set
+
+	// Range marks an entry populated through PutRange. Such entries may be sparse, so they carry no whole-file
+	// checksum; rebuildIndex instead reconstructs their chunk bitmap from the chunkmap sidecar.
+	Range bool `json:"range,omitempty"`
+}
+
+func metaPath(dataPath string) string {
+	return dataPath + ".meta"
+}
+
+func lockPath(dataPath string) string {
+	return dataPath + ".lock"
+}
+
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// entryPath returns the deterministic, two-level fanout path (similar to Go's build cache) that key is stored at
+// in durable mode: <dir>/<hash prefix>/<hash>-<sanitized key>.
+func entryPath(dir, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	hash := hex.EncodeToString(sum[:])
+
+	name := unsafeFileNameChars.ReplaceAllString(key, "_")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+
+	return filepath.Join(dir, hash[:2], hash+"-"+name)
+}
+
+// writeMetaAtomic persists meta next to dataPath via tempfile+rename, so a crash never leaves a partially written
+// sidecar behind.
+func writeMetaAtomic(dataPath string, meta entryMeta) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(dataPath)
+	tmp, err := os.CreateTemp(dir, ".meta-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+
+	return os.Rename(tmpName, metaPath(dataPath))
+}
+
+// rebuildIndex walks dir for entry metadata sidecars written by writeMetaAtomic and reconstructs the in-memory
+// index from them, skipping and removing any entry whose metadata cannot be parsed or is expired, or whose data
+// file is missing or does not match its recorded checksum. Data files with no metadata sidecar are not visited and
+// stay on disk.
+func rebuildIndex(dir string) (map[string]*item, error) {
+	cache := make(map[string]*item)
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != ".meta" {
+			return nil
+		}
+		// The data file of a key ending in ".meta" ends in ".meta" too; it is recognised by having a sidecar of its own.
+		if _, err := os.Stat(metaPath(path)); err == nil {
+			return nil
+		}
+
+		dataPath := path[:len(path)-len(".meta")]
+
+		rawMeta, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		var meta entryMeta
+		if err := json.Unmarshal(rawMeta, &meta); err != nil || time.Now().After(meta.ExpiredAt) {
+			os.Remove(path)
+			os.Remove(dataPath)
+			os.Remove(chunkMapSidecarPath(dataPath))
+			return nil
+		}
+
+		if meta.Range {
+			i, ok := rebuildRangeItem(dataPath, meta)
+			if !ok {
+				os.Remove(path)
+				os.Remove(dataPath)
+				os.Remove(chunkMapSidecarPath(dataPath))
+				return nil
+			}
+
+			cache[meta.Key] = i
+			return nil
+		}
+
+		data, ok := loadEntryData(dataPath, meta)
+		if !ok {
+			os.Remove(path)
+			os.Remove(dataPath)
+			return nil
+		}
+
+		f, err := os.OpenFile(dataPath, os.O_RDWR, 0o600)
+		if err != nil {
+			return nil
+		}
+
+		i := newItem(meta.ExpiredAt, f)
+		i.written = int64(len(data))
+		i.size = i.written
+		i.path = dataPath
+		i.closed = true
+		cache[meta.Key] = i
+
+		return nil
+	})
+
+	return cache, err
+}
+
+// loadEntryData reads dataPath's contents back and validates them against meta's checksum, returning ok=false if
+// the file is missing or corrupt.
+func loadEntryData(dataPath string, meta entryMeta) ([]byte, bool) {
+	data, err := os.ReadFile(dataPath)
+	if err != nil {
+		return nil, false
+	}
+
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != meta.Checksum {
+		return nil, false
+	}
+
+	return data, true
+}
@@ -0,0 +1,161 @@
+package filecache
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPersistentCacheSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	fc, err := New(WithPersistence(true), WithPersistentDir(dir))
+	if err != nil {
+		t.Fatalf("cannot create filecache: %v", err)
+	}
+	fc.Start()
+
+	key := "key"
+	value := []byte("value")
+
+	if err := fc.Put(key, bytes.NewReader(value)); err != nil {
+		t.Fatalf("cannot put key: %v", err)
+	}
+
+	fc.Stop()
+
+	if _, err := os.Stat(dir); err != nil {
+		t.Fatalf("persistent dir shall survive Stop: %v", err)
+	}
+
+	fc2, err := New(WithPersistence(true), WithPersistentDir(dir))
+	if err != nil {
+		t.Fatalf("cannot reopen filecache: %v", err)
+	}
+	fc2.Start()
+	defer fc2.Stop()
+
+	buffer := bytes.Buffer{}
+	if err := fc2.Get(key, &buffer); err != nil {
+		t.Fatalf("cannot get key after restart: %v", err)
+	}
+
+	if !bytes.Equal(buffer.Bytes(), value) {
+		t.Errorf("result is wrong, %+v != %+v", buffer.Bytes(), value)
+	}
+}
+
+func TestPersistentPutRangeSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	fc, err := New(WithPersistence(true), WithPersistentDir(dir))
+	if err != nil {
+		t.Fatalf("cannot create filecache: %v", err)
+	}
+	fc.Start()
+
+	key := "key"
+	if err := fc.PutRange(key, 0, bytes.NewReader([]byte("foo"))); err != nil {
+		t.Fatalf("cannot put range: %v", err)
+	}
+
+	fc.Stop()
+
+	if _, err := os.Stat(metaPath(entryPath(dir, key))); err != nil {
+		t.Fatalf("meta sidecar shall be written for a range entry: %v", err)
+	}
+
+	fc2, err := New(WithPersistence(true), WithPersistentDir(dir))
+	if err != nil {
+		t.Fatalf("cannot reopen filecache: %v", err)
+	}
+	fc2.Start()
+	defer fc2.Stop()
+
+	if !fc2.Has(key, 0, 3) {
+		t.Fatal("range shall be present after restart")
+	}
+
+	buffer := bytes.Buffer{}
+	n, err := fc2.GetRange(key, 0, 3, &buffer)
+	if err != nil {
+		t.Fatalf("cannot get range after restart: %v", err)
+	}
+	if n != 3 || !bytes.Equal(buffer.Bytes(), []byte("foo")) {
+		t.Errorf("result is wrong, %+v != %+v", buffer.Bytes(), []byte("foo"))
+	}
+}
+
+func TestPersistentPutOverwriteSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	fc, err := New(WithPersistence(true), WithPersistentDir(dir))
+	if err != nil {
+		t.Fatalf("cannot create filecache: %v", err)
+	}
+	fc.Start()
+
+	key := "key"
+	if err := fc.Put(key, bytes.NewReader([]byte("v1"))); err != nil {
+		t.Fatalf("cannot put key: %v", err)
+	}
+	if err := fc.Put(key, bytes.NewReader([]byte("v2"))); err != nil {
+		t.Fatalf("cannot overwrite key: %v", err)
+	}
+
+	fc.Stop()
+
+	fc2, err := New(WithPersistence(true), WithPersistentDir(dir))
+	if err != nil {
+		t.Fatalf("cannot reopen filecache: %v", err)
+	}
+	fc2.Start()
+	defer fc2.Stop()
+
+	buffer := bytes.Buffer{}
+	if err := fc2.Get(key, &buffer); err != nil {
+		t.Fatalf("cannot get key after restart: %v", err)
+	}
+
+	if !bytes.Equal(buffer.Bytes(), []byte("v2")) {
+		t.Errorf("result is wrong, %+v != %+v", buffer.Bytes(), []byte("v2"))
+	}
+}
+
+func TestPersistentCacheDropsCorruptEntry(t *testing.T) {
+	dir := t.TempDir()
+
+	fc, err := New(WithPersistence(true), WithPersistentDir(dir))
+	if err != nil {
+		t.Fatalf("cannot create filecache: %v", err)
+	}
+	fc.Start()
+
+	key := "key"
+	if err := fc.Put(key, bytes.NewReader([]byte("value"))); err != nil {
+		t.Fatalf("cannot put key: %v", err)
+	}
+	fc.Stop()
+
+	dataPath := entryPath(dir, key)
+	if err := os.WriteFile(dataPath, []byte("corrupted"), 0o600); err != nil {
+		t.Fatalf("cannot corrupt data file: %v", err)
+	}
+
+	fc2, err := New(WithPersistence(true), WithPersistentDir(dir))
+	if err != nil {
+		t.Fatalf("cannot reopen filecache: %v", err)
+	}
+	fc2.Start()
+	defer fc2.Stop()
+
+	buffer := bytes.Buffer{}
+	if err := fc2.Get(key, &buffer); err != ErrNotFound {
+		t.Errorf("corrupted entry shall not be found, got err=%v", err)
+	}
+
+	if _, err := os.Stat(filepath.Dir(dataPath)); err != nil {
+		t.Fatalf("fanout directory shall still exist: %v", err)
+	}
+}
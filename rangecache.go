@@ -0,0 +1,312 @@
+package filecache
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// defaultChunkSize is the granularity at which PutRange/GetRange track which parts of an item have been
+// populated, mirroring the chunked sparse-file approach used by tools like rclone's vfs cache.
+const defaultChunkSize int64 = 1 << 20 // 1 MiB
+
+// chunkMap is a bitmap recording which fixed-size chunks of an item are present. It is safe for concurrent use.
+type chunkMap struct {
+	mu        sync.Mutex
+	chunkSize int64
+	bits      []byte
+}
+
+func newChunkMap(chunkSize int64) *chunkMap {
+	return &chunkMap{chunkSize: chunkSize}
+}
+
+func (m *chunkMap) markRange(offset, length int64) {
+	if length <= 0 {
+		return
+	}
+	first := offset / m.chunkSize
+	last := (offset + length - 1) / m.chunkSize
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	need := int(last/8) + 1
+	if len(m.bits) < need {
+		grown := make([]byte, need)
+		copy(grown, m.bits)
+		m.bits = grown
+	}
+
+	for c := first; c <= last; c++ {
+		m.bits[c/8] |= 1 << uint(c%8)
+	}
+}
+
+func (m *chunkMap) hasRange(offset, length int64) bool {
+	if length <= 0 {
+		return true
+	}
+	first := offset / m.chunkSize
+	last := (offset + length - 1) / m.chunkSize
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for c := first; c <= last; c++ {
+		idx := int(c / 8)
+		if idx >= len(m.bits) || m.bits[idx]&(1<<uint(c%8)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// snapshot returns a copy of the bitmap suitable for writing to the sidecar file.
+func (m *chunkMap) snapshot() []byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make([]byte, len(m.bits))
+	copy(out, m.bits)
+
+	return out
+}
+
+// chunkMapSidecarPath returns the path of the sidecar file that persists dataPath's chunk bitmap, so a durable
+// cache (see WithPersistence) can tell which parts of a partially-populated item survived a restart.
+func chunkMapSidecarPath(dataPath string) string {
+	return dataPath + ".chunkmap"
+}
+
+// PutRange writes r into key starting at offset, without requiring the rest of the value to be present. It can be
+// called multiple times, with different offsets, to progressively populate a single key.
+func (fc *FileCache) PutRange(key string, offset int64, r io.Reader) error {
+	if fc.opts.persistent {
+		return fc.putRangePersistent(key, offset, r)
+	}
+
+	fc.lock.Lock()
+	i, ok := fc.cache[key]
+	if !ok {
+		f, err := os.CreateTemp(fc.workdir, "cache-*")
+		if err != nil {
+			fc.lock.Unlock()
+			return err
+		}
+
+		i = newItem(time.Now().Add(fc.opts.timeToLive), f)
+		i.path = f.Name()
+		i.chunks = newChunkMap(defaultChunkSize)
+		fc.cache[key] = i
+	} else if i.chunks == nil {
+		fc.lock.Unlock()
+		return ErrWholeValuePopulated
+	}
+	fc.lock.Unlock()
+
+	pos, err := writeRangeChunks(i, offset, r)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(chunkMapSidecarPath(i.path), i.chunks.snapshot(), 0o600); err != nil {
+		return err
+	}
+
+	fc.lock.Lock()
+	if pos > i.size {
+		fc.accountSize(key, i, pos)
+	}
+	fc.lock.Unlock()
+
+	return nil
+}
+
+// putRangePersistent is the durable-mode counterpart of PutRange. Unlike putPersistent, it cannot write to a
+// temporary file and rename it into place once finished, since a range item is built up across multiple calls at
+// arbitrary offsets: it writes directly to key's deterministic entryPath under a per-key flock, then atomically
+// refreshes a meta sidecar (marked Range, so rebuildIndex knows to reconstruct the item from the chunkmap sidecar
+// rather than by checksum) after every call.
+func (fc *FileCache) putRangePersistent(key string, offset int64, r io.Reader) error {
+	finalPath := entryPath(fc.workdir, key)
+	dir := filepath.Dir(finalPath)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	lock, err := os.OpenFile(lockPath(finalPath), os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+
+	if err := flockFile(lock); err != nil {
+		return err
+	}
+	defer funlockFile(lock)
+
+	fc.lock.Lock()
+	i, ok := fc.cache[key]
+	if !ok {
+		f, err := os.OpenFile(finalPath, os.O_CREATE|os.O_RDWR, 0o600)
+		if err != nil {
+			fc.lock.Unlock()
+			return err
+		}
+
+		i = newItem(time.Now().Add(fc.opts.timeToLive), f)
+		i.path = finalPath
+		i.chunks = newChunkMap(defaultChunkSize)
+		fc.cache[key] = i
+	} else if i.chunks == nil {
+		fc.lock.Unlock()
+		return ErrWholeValuePopulated
+	}
+	fc.lock.Unlock()
+
+	pos, err := writeRangeChunks(i, offset, r)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(chunkMapSidecarPath(finalPath), i.chunks.snapshot(), 0o600); err != nil {
+		return err
+	}
+
+	fc.lock.Lock()
+	if pos > i.size {
+		fc.accountSize(key, i, pos)
+	}
+	size := i.size
+	fc.lock.Unlock()
+
+	return writeMetaAtomic(finalPath, entryMeta{
+		Key:       key,
+		ExpiredAt: i.expiredAt,
+		Size:      size,
+		Range:     true,
+	})
+}
+
+// writeRangeChunks writes r into i starting at offset, marking each chunk as populated as it lands, and returns the
+// offset one past the last byte written.
+func writeRangeChunks(i *item, offset int64, r io.Reader) (int64, error) {
+	buf := make([]byte, 32*1024)
+	pos := offset
+	for {
+		n, rerr := r.Read(buf)
+		if n > 0 {
+			if _, werr := i.file.WriteAt(buf[:n], pos); werr != nil {
+				return pos, werr
+			}
+
+			i.chunks.markRange(pos, int64(n))
+			pos += int64(n)
+		}
+
+		if rerr == io.EOF {
+			return pos, nil
+		}
+		if rerr != nil {
+			return pos, rerr
+		}
+	}
+}
+
+// rebuildRangeItem reconstructs a range-mode item (one populated through PutRange) from dataPath and its chunkmap
+// sidecar, returning ok=false if either the data file or the sidecar is missing or unreadable.
+func rebuildRangeItem(dataPath string, meta entryMeta) (*item, bool) {
+	bits, err := loadChunkMap(dataPath)
+	if err != nil {
+		return nil, false
+	}
+
+	f, err := os.OpenFile(dataPath, os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, false
+	}
+
+	i := newItem(meta.ExpiredAt, f)
+	i.path = dataPath
+	i.size = meta.Size
+	i.closed = true
+	i.chunks = &chunkMap{chunkSize: defaultChunkSize, bits: bits}
+
+	return i, true
+}
+
+// loadChunkMap reads back the bitmap PutRange persisted alongside dataPath.
+func loadChunkMap(dataPath string) ([]byte, error) {
+	return os.ReadFile(chunkMapSidecarPath(dataPath))
+}
+
+// GetRange reads the [offset, offset+length) byte range of key into w, returning the number of bytes copied.
+// ErrNotFound is returned if key is unknown, or if any chunk (see defaultChunkSize) the requested range touches has
+// not been populated yet. A chunk counts as populated once any byte of it has been written, so bytes of such a
+// chunk that were never written read back as zeros, and a range running past the last written byte is cut short
+// without an error; the returned count shows how many bytes were copied.
+func (fc *FileCache) GetRange(key string, offset, length int64, w io.Writer) (int64, error) {
+	fc.lock.RLock()
+	i, ok := fc.cache[key]
+	fc.lock.RUnlock()
+
+	if !ok {
+		fc.recordMiss()
+		return 0, ErrNotFound
+	}
+
+	i.mu.Lock()
+	expired := time.Now().After(i.expiredAt)
+	if !expired {
+		i.expiredAt = time.Now().Add(fc.opts.timeToLive)
+	}
+	i.mu.Unlock()
+
+	if expired {
+		fc.lock.Lock()
+		fc.expireLocked(key)
+		fc.lock.Unlock()
+		fc.recordMiss()
+		return 0, ErrNotFound
+	}
+
+	if i.chunks == nil || !i.chunks.hasRange(offset, length) {
+		fc.recordMiss()
+		return 0, ErrNotFound
+	}
+
+	fc.lock.Lock()
+	fc.touchLRU(key)
+	fc.lock.Unlock()
+	fc.recordHit()
+
+	i.acquireReader()
+	defer i.releaseReader()
+
+	return io.Copy(w, io.NewSectionReader(i.file, offset, length))
+}
+
+// Has probes whether every chunk the [offset, offset+length) byte range of key touches is populated, without
+// reading it. As with GetRange, a chunk counts as populated once any byte of it has been written.
+func (fc *FileCache) Has(key string, offset, length int64) bool {
+	fc.lock.RLock()
+	i, ok := fc.cache[key]
+	fc.lock.RUnlock()
+
+	if !ok || i.chunks == nil {
+		return false
+	}
+
+	i.mu.Lock()
+	expired := time.Now().After(i.expiredAt)
+	i.mu.Unlock()
+	if expired {
+		return false
+	}
+
+	return i.chunks.hasRange(offset, length)
+}
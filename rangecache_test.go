@@ -0,0 +1,129 @@
+package filecache
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestPutRangeGetRange(t *testing.T) {
+	fc, err := New()
+	if err != nil {
+		t.Error("cannot create filecache")
+	}
+
+	fc.Start()
+	defer fc.Stop()
+
+	key := "key"
+
+	if err := fc.PutRange(key, 0, bytes.NewReader([]byte("foo"))); err != nil {
+		t.Errorf("cannot put range: %v", err)
+	}
+
+	buffer := bytes.Buffer{}
+	n, err := fc.GetRange(key, 0, 3, &buffer)
+	if err != nil {
+		t.Errorf("cannot get range: %v", err)
+	}
+	if n != 3 || !bytes.Equal(buffer.Bytes(), []byte("foo")) {
+		t.Errorf("result is wrong, %+v != %+v", buffer.Bytes(), []byte("foo"))
+	}
+
+	if !fc.Has(key, 0, 3) {
+		t.Error("range shall be present")
+	}
+}
+
+func TestGetRangePartiallyPopulated(t *testing.T) {
+	fc, err := New()
+	if err != nil {
+		t.Error("cannot create filecache")
+	}
+
+	fc.Start()
+	defer fc.Stop()
+
+	key := "key"
+
+	if err := fc.PutRange(key, 0, bytes.NewReader([]byte("foo"))); err != nil {
+		t.Errorf("cannot put range: %v", err)
+	}
+
+	if fc.Has(key, 0, defaultChunkSize+1) {
+		t.Error("range beyond what was written shall not be present")
+	}
+
+	buffer := bytes.Buffer{}
+	_, err = fc.GetRange(key, 0, defaultChunkSize+1, &buffer)
+	if err != ErrNotFound {
+		t.Errorf("expected ErrNotFound for a partially populated range, got %v", err)
+	}
+}
+
+func TestGetOnRangePopulatedKeyDoesNotHang(t *testing.T) {
+	fc, err := New()
+	if err != nil {
+		t.Fatalf("cannot create filecache: %v", err)
+	}
+
+	fc.Start()
+	defer fc.Stop()
+
+	key := "key"
+	if err := fc.PutRange(key, 0, bytes.NewReader([]byte("foo"))); err != nil {
+		t.Fatalf("cannot put range: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fc.Get(key, &bytes.Buffer{})
+	}()
+
+	select {
+	case err := <-done:
+		if err != ErrRangePopulated {
+			t.Errorf("expected ErrRangePopulated, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Get on a range-populated key hung instead of returning an error")
+	}
+}
+
+func TestPutRangeOnWholeValuePopulatedKeyReturnsError(t *testing.T) {
+	fc, err := New()
+	if err != nil {
+		t.Fatalf("cannot create filecache: %v", err)
+	}
+
+	fc.Start()
+	defer fc.Stop()
+
+	key := "key"
+	if err := fc.Put(key, bytes.NewReader([]byte("foo"))); err != nil {
+		t.Fatalf("cannot put key: %v", err)
+	}
+
+	if err := fc.PutRange(key, 0, bytes.NewReader([]byte("bar"))); err != ErrWholeValuePopulated {
+		t.Errorf("expected ErrWholeValuePopulated, got %v", err)
+	}
+}
+
+func TestGetRangeNotFound(t *testing.T) {
+	fc, err := New()
+	if err != nil {
+		t.Error("cannot create filecache")
+	}
+
+	fc.Start()
+	defer fc.Stop()
+
+	buffer := bytes.Buffer{}
+	if _, err := fc.GetRange("key", 0, 1, &buffer); err != ErrNotFound {
+		t.Error("key shall not be found")
+	}
+
+	if fc.Has("key", 0, 1) {
+		t.Error("key shall not be present")
+	}
+}
@@ -0,0 +1,190 @@
+package filecache
+
+import "os"
+
+// Stats is a point-in-time snapshot of cache activity and occupancy, returned by FileCache.Stats.
+type Stats struct {
+	Hits      int64
+	Misses    int64
+	Evictions int64
+	Bytes     int64
+	Entries   int
+}
+
+// Stats returns a snapshot of the cache's hit/miss/eviction counters along with its current size.
+func (fc *FileCache) Stats() Stats {
+	fc.statsMu.Lock()
+	hits, misses, evictions := fc.hits, fc.misses, fc.evictions
+	fc.statsMu.Unlock()
+
+	fc.lock.RLock()
+	defer fc.lock.RUnlock()
+
+	return Stats{
+		Hits:      hits,
+		Misses:    misses,
+		Evictions: evictions,
+		Bytes:     fc.totalBytes,
+		Entries:   len(fc.cache),
+	}
+}
+
+func (fc *FileCache) recordHit() {
+	fc.statsMu.Lock()
+	fc.hits++
+	fc.statsMu.Unlock()
+}
+
+func (fc *FileCache) recordMiss() {
+	fc.statsMu.Lock()
+	fc.misses++
+	fc.statsMu.Unlock()
+}
+
+// seedLRU populates the LRU list from an index rebuilt by rebuildIndex. The on-disk order doesn't reflect real
+// recency, but entries still need to be in the list so later Get calls and eviction work.
+func (fc *FileCache) seedLRU() {
+	for key, i := range fc.cache {
+		fc.lruElems[key] = fc.lru.PushFront(key)
+		fc.totalBytes += i.size
+	}
+}
+
+// replaceLocked inserts i as key's entry, tearing down whatever previously lived at that key so a repeated Put
+// doesn't leak the old entry's disk footprint or byte accounting. Callers must hold fc.lock.
+func (fc *FileCache) replaceLocked(key string, i *item) {
+	if old, ok := fc.cache[key]; ok {
+		fc.removeLocked(key)
+
+		if old.path != "" && old.path == i.path {
+			// In durable mode, entryPath is deterministic, so a repeated Put on the same key reuses the exact
+			// path old already lived at: by the time we get here, i's data/meta have already been renamed into
+			// place there. Unlinking path now, the way removeItemFiles normally would, would delete the entry we
+			// just wrote instead of the stale one. Just reclaim old's now-dangling file descriptor.
+			old.closeFile()
+		} else {
+			removeItemFiles(fc.opts.persistent, old)
+		}
+	}
+
+	fc.cache[key] = i
+}
+
+// touchLRU marks key as the most recently used entry. Callers must hold fc.lock.
+func (fc *FileCache) touchLRU(key string) {
+	if elem, ok := fc.lruElems[key]; ok {
+		fc.lru.MoveToFront(elem)
+		return
+	}
+
+	fc.lruElems[key] = fc.lru.PushFront(key)
+}
+
+// accountSize records key's current size for WithMaxBytes bookkeeping and evicts least-recently-used entries, if
+// needed, to stay within the configured caps. Callers must hold fc.lock and have already stored i in fc.cache.
+func (fc *FileCache) accountSize(key string, i *item, size int64) {
+	fc.totalBytes += size - i.size
+	i.size = size
+
+	fc.touchLRU(key)
+	fc.evictUntilWithinCaps(key)
+}
+
+// evictUntilWithinCaps evicts least-recently-used entries until the cache is within WithMaxBytes/WithMaxEntries,
+// without ever evicting keepKey (the entry that was just written). Callers must hold fc.lock.
+func (fc *FileCache) evictUntilWithinCaps(keepKey string) {
+	for fc.overCap() {
+		elem := fc.lru.Back()
+		if elem == nil {
+			return
+		}
+
+		key := elem.Value.(string)
+		if key == keepKey {
+			elem = elem.Prev()
+			if elem == nil {
+				return
+			}
+			key = elem.Value.(string)
+		}
+
+		fc.evictLocked(key)
+	}
+}
+
+func (fc *FileCache) overCap() bool {
+	if fc.opts.maxBytes > 0 && fc.totalBytes > fc.opts.maxBytes {
+		return true
+	}
+	if fc.opts.maxEntries > 0 && len(fc.cache) > fc.opts.maxEntries {
+		return true
+	}
+	return false
+}
+
+// removeLocked drops key from cache, the LRU list and the byte total, without treating it as an eviction.
+// Callers must hold fc.lock.
+func (fc *FileCache) removeLocked(key string) {
+	i, ok := fc.cache[key]
+	if !ok {
+		return
+	}
+
+	delete(fc.cache, key)
+	fc.totalBytes -= i.size
+
+	if elem, ok := fc.lruElems[key]; ok {
+		fc.lru.Remove(elem)
+		delete(fc.lruElems, key)
+	}
+}
+
+// evictLocked removes key the way removeLocked does, additionally counting it as an eviction and reclaiming its
+// files on disk. Callers must hold fc.lock.
+func (fc *FileCache) evictLocked(key string) {
+	i, ok := fc.cache[key]
+	if !ok {
+		return
+	}
+
+	fc.removeLocked(key)
+
+	fc.statsMu.Lock()
+	fc.evictions++
+	fc.statsMu.Unlock()
+
+	removeItemFiles(fc.opts.persistent, i)
+}
+
+// expireLocked removes key because its TTL ran out, reclaiming its files on disk the same way evictLocked does,
+// but without counting it as an eviction. Callers must hold fc.lock.
+func (fc *FileCache) expireLocked(key string) {
+	i, ok := fc.cache[key]
+	if !ok {
+		return
+	}
+
+	fc.removeLocked(key)
+	removeItemFiles(fc.opts.persistent, i)
+}
+
+// removeItemFiles best-effort reclaims an item's resources: its file descriptor, and its on-disk footprint (data
+// file and chunkmap sidecar, and in durable mode its metadata and lock sidecars too). The file descriptor is
+// released through closeFile, so a reader already attached to the item (e.g. a concurrent Get) finishes its read
+// before the descriptor is actually closed.
+func removeItemFiles(persistent bool, i *item) {
+	path := i.path
+	if path == "" {
+		path = i.file.Name()
+	}
+
+	i.closeFile()
+
+	os.Remove(path)
+	os.Remove(chunkMapSidecarPath(path))
+
+	if persistent {
+		os.Remove(metaPath(path))
+		os.Remove(lockPath(path))
+	}
+}
@@ -0,0 +1,193 @@
+package filecache
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// blockingWriter blocks its first Write until unblock is closed, so a test can pause an in-flight Get mid-copy.
+type blockingWriter struct {
+	buf     bytes.Buffer
+	unblock chan struct{}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	<-w.unblock
+	return w.buf.Write(p)
+}
+
+func TestMaxEntriesEvictsLeastRecentlyUsed(t *testing.T) {
+	fc, err := New(WithMaxEntries(2))
+	if err != nil {
+		t.Fatalf("cannot create filecache: %v", err)
+	}
+	fc.Start()
+	defer fc.Stop()
+
+	if err := fc.Put("a", bytes.NewReader([]byte("1"))); err != nil {
+		t.Fatalf("cannot put a: %v", err)
+	}
+	if err := fc.Put("b", bytes.NewReader([]byte("2"))); err != nil {
+		t.Fatalf("cannot put b: %v", err)
+	}
+
+	// Touch "a" so "b" becomes the least recently used entry.
+	if err := fc.Get("a", &bytes.Buffer{}); err != nil {
+		t.Fatalf("cannot get a: %v", err)
+	}
+
+	if err := fc.Put("c", bytes.NewReader([]byte("3"))); err != nil {
+		t.Fatalf("cannot put c: %v", err)
+	}
+
+	if err := fc.Get("b", &bytes.Buffer{}); err != ErrNotFound {
+		t.Errorf("expected b to be evicted, got err=%v", err)
+	}
+
+	if err := fc.Get("a", &bytes.Buffer{}); err != nil {
+		t.Errorf("a shall still be present: %v", err)
+	}
+	if err := fc.Get("c", &bytes.Buffer{}); err != nil {
+		t.Errorf("c shall still be present: %v", err)
+	}
+
+	stats := fc.Stats()
+	if stats.Entries != 2 {
+		t.Errorf("expected 2 entries, got %d", stats.Entries)
+	}
+	if stats.Evictions != 1 {
+		t.Errorf("expected 1 eviction, got %d", stats.Evictions)
+	}
+}
+
+func TestMaxBytesEvictsUntilWithinCap(t *testing.T) {
+	fc, err := New(WithMaxBytes(5))
+	if err != nil {
+		t.Fatalf("cannot create filecache: %v", err)
+	}
+	fc.Start()
+	defer fc.Stop()
+
+	if err := fc.Put("a", bytes.NewReader([]byte("abc"))); err != nil {
+		t.Fatalf("cannot put a: %v", err)
+	}
+	if err := fc.Put("b", bytes.NewReader([]byte("defg"))); err != nil {
+		t.Fatalf("cannot put b: %v", err)
+	}
+
+	stats := fc.Stats()
+	if stats.Bytes > 5 {
+		t.Errorf("expected total bytes to stay within cap, got %d", stats.Bytes)
+	}
+
+	if err := fc.Get("a", &bytes.Buffer{}); err != ErrNotFound {
+		t.Errorf("expected a to be evicted to make room for b, got err=%v", err)
+	}
+}
+
+func TestEvictionClosesFileDescriptor(t *testing.T) {
+	fc, err := New(WithMaxEntries(1))
+	if err != nil {
+		t.Fatalf("cannot create filecache: %v", err)
+	}
+	fc.Start()
+	defer fc.Stop()
+
+	if err := fc.Put("a", bytes.NewReader([]byte("1"))); err != nil {
+		t.Fatalf("cannot put a: %v", err)
+	}
+
+	fc.lock.RLock()
+	evicted := fc.cache["a"]
+	fc.lock.RUnlock()
+
+	if err := fc.Put("b", bytes.NewReader([]byte("2"))); err != nil {
+		t.Fatalf("cannot put b: %v", err)
+	}
+
+	if _, err := evicted.file.Write([]byte("x")); err == nil {
+		t.Error("evicted item's file descriptor shall be closed")
+	}
+}
+
+func TestGetInFlightSurvivesConcurrentEviction(t *testing.T) {
+	fc, err := New(WithMaxEntries(1))
+	if err != nil {
+		t.Fatalf("cannot create filecache: %v", err)
+	}
+	fc.Start()
+	defer fc.Stop()
+
+	if err := fc.Put("a", bytes.NewReader([]byte("hello"))); err != nil {
+		t.Fatalf("cannot put a: %v", err)
+	}
+
+	w := &blockingWriter{unblock: make(chan struct{})}
+	done := make(chan error, 1)
+	go func() {
+		done <- fc.Get("a", w)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		fc.lock.RLock()
+		i := fc.cache["a"]
+		fc.lock.RUnlock()
+
+		i.mu.Lock()
+		readers := i.readers
+		i.mu.Unlock()
+
+		if readers > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Get never registered as an in-flight reader")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// "a" is now the only entry, so this eviction targets it while its Get is still in flight.
+	if err := fc.Put("b", bytes.NewReader([]byte("world"))); err != nil {
+		t.Fatalf("cannot put b: %v", err)
+	}
+
+	close(w.unblock)
+
+	if err := <-done; err != nil {
+		t.Errorf("in-flight Get shall not fail because of a concurrent eviction: %v", err)
+	}
+
+	if !bytes.Equal(w.buf.Bytes(), []byte("hello")) {
+		t.Errorf("result is wrong, %+v != %+v", w.buf.Bytes(), []byte("hello"))
+	}
+}
+
+func TestStatsTracksHitsAndMisses(t *testing.T) {
+	fc, err := New()
+	if err != nil {
+		t.Fatalf("cannot create filecache: %v", err)
+	}
+	fc.Start()
+	defer fc.Stop()
+
+	if err := fc.Put("key", bytes.NewReader([]byte("value"))); err != nil {
+		t.Fatalf("cannot put key: %v", err)
+	}
+
+	if err := fc.Get("key", &bytes.Buffer{}); err != nil {
+		t.Fatalf("cannot get key: %v", err)
+	}
+	if err := fc.Get("missing", &bytes.Buffer{}); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	stats := fc.Stats()
+	if stats.Hits != 1 {
+		t.Errorf("expected 1 hit, got %d", stats.Hits)
+	}
+	if stats.Misses != 1 {
+		t.Errorf("expected 1 miss, got %d", stats.Misses)
+	}
+}
@@ -0,0 +1,126 @@
+package filecache
+
+import (
+	"bytes"
+	"context"
+	"time"
+)
+
+type typedCacheOptions struct {
+	codec         Codec
+	loaderTimeout time.Duration
+}
+
+func getDefaultTypedCacheOptions() typedCacheOptions {
+	return typedCacheOptions{
+		codec: JSONCodec{},
+	}
+}
+
+type TypedCacheOption func(*typedCacheOptions)
+
+// WithCodec overrides the Codec used to marshal/unmarshal values. The default is JSONCodec.
+func WithCodec(codec Codec) TypedCacheOption {
+	return func(opts *typedCacheOptions) {
+		opts.codec = codec
+	}
+}
+
+// WithLoaderTimeout bounds how long GetOrSet's loader is given to populate a missing key. The default, zero,
+// means no timeout is applied beyond the context the caller passes in.
+func WithLoaderTimeout(timeout time.Duration) TypedCacheOption {
+	return func(opts *typedCacheOptions) {
+		opts.loaderTimeout = timeout
+	}
+}
+
+// TypedCache wraps a FileCache with a Codec, so callers can store and retrieve Go values directly instead of
+// dealing with bytes.
+type TypedCache[T any] struct {
+	fc   *FileCache
+	opts typedCacheOptions
+
+	sf singleflightGroup
+}
+
+// NewTypedCache wraps fc as a TypedCache[T]. fc's lifecycle (Start/Stop) is still managed independently.
+func NewTypedCache[T any](fc *FileCache, opts ...TypedCacheOption) *TypedCache[T] {
+	tc := &TypedCache[T]{fc: fc}
+
+	tc.opts = getDefaultTypedCacheOptions()
+	for _, opt := range opts {
+		opt(&tc.opts)
+	}
+
+	return tc
+}
+
+// Get decodes the value stored for key. ErrNotFound will be returned if the key is not found.
+func (tc *TypedCache[T]) Get(key string) (T, error) {
+	var zero T
+
+	buf := bytes.Buffer{}
+	if err := tc.fc.Get(key, &buf); err != nil {
+		return zero, err
+	}
+
+	var v T
+	if err := tc.opts.codec.Unmarshal(buf.Bytes(), &v); err != nil {
+		return zero, err
+	}
+
+	return v, nil
+}
+
+// Set encodes v and stores it for key.
+func (tc *TypedCache[T]) Set(key string, v T) error {
+	data, err := tc.opts.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return tc.fc.Put(key, bytes.NewReader(data))
+}
+
+// GetOrSet returns the value cached for key, populating it via loader on a miss. Concurrent GetOrSet calls for
+// the same key share a single loader invocation: every caller gets that call's result. loader is given a context
+// that is cancelled once WithLoaderTimeout elapses, if set.
+func (tc *TypedCache[T]) GetOrSet(ctx context.Context, key string, loader func(ctx context.Context) (T, error)) (T, error) {
+	var zero T
+
+	if v, err := tc.Get(key); err == nil {
+		return v, nil
+	} else if err != ErrNotFound {
+		return zero, err
+	}
+
+	result, err := tc.sf.Do(key, func() (any, error) {
+		// Another caller may have populated key while we were waiting to enter the singleflight group.
+		if v, err := tc.Get(key); err == nil {
+			return v, nil
+		}
+
+		loadCtx := ctx
+		if tc.opts.loaderTimeout > 0 {
+			var cancel context.CancelFunc
+			loadCtx, cancel = context.WithTimeout(ctx, tc.opts.loaderTimeout)
+			defer cancel()
+		}
+
+		v, err := loader(loadCtx)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := tc.Set(key, v); err != nil {
+			return nil, err
+		}
+
+		return v, nil
+	})
+	if err != nil {
+		return zero, err
+	}
+
+	return result.(T), nil
+}
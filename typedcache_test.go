@@ -0,0 +1,101 @@
+package filecache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type widget struct {
+	Name  string
+	Count int
+}
+
+func TestTypedCacheGetSet(t *testing.T) {
+	fc, err := New()
+	if err != nil {
+		t.Fatalf("cannot create filecache: %v", err)
+	}
+	fc.Start()
+	defer fc.Stop()
+
+	tc := NewTypedCache[widget](fc)
+
+	want := widget{Name: "gear", Count: 3}
+	if err := tc.Set("key", want); err != nil {
+		t.Fatalf("cannot set key: %v", err)
+	}
+
+	got, err := tc.Get("key")
+	if err != nil {
+		t.Fatalf("cannot get key: %v", err)
+	}
+	if got != want {
+		t.Errorf("result is wrong, %+v != %+v", got, want)
+	}
+}
+
+func TestTypedCacheGetOrSetLoadsOnce(t *testing.T) {
+	fc, err := New()
+	if err != nil {
+		t.Fatalf("cannot create filecache: %v", err)
+	}
+	fc.Start()
+	defer fc.Stop()
+
+	tc := NewTypedCache[widget](fc)
+
+	var loads int32
+	loader := func(ctx context.Context) (widget, error) {
+		atomic.AddInt32(&loads, 1)
+		time.Sleep(10 * time.Millisecond)
+		return widget{Name: "gear", Count: 1}, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]widget, 10)
+	for idx := 0; idx < 10; idx++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			v, err := tc.GetOrSet(context.Background(), "key", loader)
+			if err != nil {
+				t.Errorf("cannot get or set: %v", err)
+			}
+			results[i] = v
+		}(idx)
+	}
+	wg.Wait()
+
+	if loads != 1 {
+		t.Errorf("expected loader to run exactly once, ran %d times", loads)
+	}
+
+	for _, got := range results {
+		if got != (widget{Name: "gear", Count: 1}) {
+			t.Errorf("result is wrong: %+v", got)
+		}
+	}
+}
+
+func TestTypedCacheGetOrSetLoaderTimeout(t *testing.T) {
+	fc, err := New()
+	if err != nil {
+		t.Fatalf("cannot create filecache: %v", err)
+	}
+	fc.Start()
+	defer fc.Stop()
+
+	tc := NewTypedCache[widget](fc, WithLoaderTimeout(10*time.Millisecond))
+
+	loader := func(ctx context.Context) (widget, error) {
+		<-ctx.Done()
+		return widget{}, ctx.Err()
+	}
+
+	if _, err := tc.GetOrSet(context.Background(), "key", loader); err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}